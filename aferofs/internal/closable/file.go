@@ -21,6 +21,17 @@ func NewFile[T afero.File](inner afero.File) *Closable[T] {
 	return &Closable[T]{inner: inner}
 }
 
+// Inner returns the wrapped file.
+// It returns fs.ErrClosed if f is already closed.
+func (f *Closable[T]) Inner() (afero.File, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return nil, fs.ErrClosed
+	}
+	return f.inner, nil
+}
+
 func (f *Closable[T]) beforeEach(ms string, _ ...any) error {
 	f.mu.RLock()
 	if f.closed {
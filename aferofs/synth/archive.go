@@ -0,0 +1,247 @@
+package synth
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ngicks/go-fsys-helper/aferofs/internal/bufpool"
+)
+
+// ArchiveFormat is a format of archive accepted by [Fs.AddArchive].
+type ArchiveFormat int
+
+const (
+	// ArchiveTar is a tar archive read by archive/tar.
+	// Compressed archives must be decompressed by the caller.
+	ArchiveTar ArchiveFormat = iota
+	// ArchiveZip is a zip archive read by archive/zip.
+	ArchiveZip
+)
+
+func (f ArchiveFormat) String() string {
+	switch f {
+	case ArchiveTar:
+		return "tar"
+	case ArchiveZip:
+		return "zip"
+	default:
+		return fmt.Sprintf("ArchiveFormat(%d)", int(f))
+	}
+}
+
+type archiveEntry struct {
+	path    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	uid     int
+	gid     int
+}
+
+// AddArchive reads the archive from r and adds its contents into fsys.
+//
+// Contents of regular files are extracted into FileViews allocated by the allocator passed to [New].
+// It returns syscall.EROFS if fsys is created without an allocator.
+// Files are added by [Fs.AddFile] so existing files at the same path are replaced.
+// Permission, modification time and owner of files and directories are preserved.
+// Metadata of directories is applied after all entries are added, like tar(1) does,
+// so directories without write permission can still be populated.
+//
+// Hard links in tar archives are added by [Fs.AddLink];
+// the linked file must precede the link in the archive.
+// Like [Fs.Copy], other non regular files, e.g. symlinks and devices, are skipped.
+//
+// For ArchiveZip, r must implement io.ReaderAt and io.Seeker,
+// since the zip format places its index at the end of the archive.
+//
+// Entries are added as they are read. If an error is returned,
+// entries read before the error remain in fsys.
+func (fsys *Fs) AddArchive(r io.Reader, format ArchiveFormat) error {
+	if fsys.allocator == nil {
+		return wrapErr("AddArchive", "", syscall.EROFS)
+	}
+	var (
+		dirs []archiveEntry
+		err  error
+	)
+	switch format {
+	case ArchiveTar:
+		dirs, err = fsys.addTar(r)
+	case ArchiveZip:
+		dirs, err = fsys.addZip(r)
+	default:
+		return fmt.Errorf("%w: unknown format %s", fs.ErrInvalid, format)
+	}
+	if err != nil {
+		return err
+	}
+	// Apply in reverse order so that children are processed before their parents.
+	for _, dir := range slices.Backward(dirs) {
+		if err := fsys.applyArchiveMeta(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fsys *Fs) addTar(r io.Reader) ([]archiveEntry, error) {
+	var dirs []archiveEntry
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return dirs, nil
+		}
+		if err != nil {
+			return dirs, fmt.Errorf("reading tar: %w", err)
+		}
+		ent, err := newArchiveEntry(h.Name, h.FileInfo().Mode(), h.ModTime, h.Uid, h.Gid)
+		if err != nil {
+			return dirs, err
+		}
+		ent.size = h.Size
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := fsys.MkdirAll(ent.path, fs.ModePerm); err != nil {
+				return dirs, err
+			}
+			dirs = append(dirs, ent)
+		case tar.TypeReg:
+			if err := fsys.addArchiveFile(ent, tr); err != nil {
+				return dirs, err
+			}
+		case tar.TypeLink:
+			existing, err := cleanArchivePath(h.Linkname)
+			if err != nil {
+				return dirs, err
+			}
+			if err := fsys.AddLink(ent.path, existing); err != nil {
+				return dirs, err
+			}
+		}
+	}
+}
+
+func (fsys *Fs) addZip(r io.Reader) ([]archiveEntry, error) {
+	ra, ok := r.(io.ReaderAt)
+	seeker, ok2 := r.(io.Seeker)
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("%w: zip archive must implement io.ReaderAt and io.Seeker", fs.ErrInvalid)
+	}
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("reading zip: %w", err)
+	}
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, fmt.Errorf("reading zip: %w", err)
+	}
+
+	var dirs []archiveEntry
+	for _, zf := range zr.File {
+		mode := zf.Mode()
+		ent, err := newArchiveEntry(zf.Name, mode, zf.Modified, 0, 0)
+		if err != nil {
+			return dirs, err
+		}
+		ent.size = int64(zf.UncompressedSize64)
+		switch {
+		case mode.IsDir():
+			if err := fsys.MkdirAll(ent.path, fs.ModePerm); err != nil {
+				return dirs, err
+			}
+			dirs = append(dirs, ent)
+		case mode.IsRegular():
+			err := func() error {
+				f, err := zf.Open()
+				if err != nil {
+					return fmt.Errorf("reading zip: %w", err)
+				}
+				defer f.Close()
+				return fsys.addArchiveFile(ent, f)
+			}()
+			if err != nil {
+				return dirs, err
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// cleanArchivePath converts name of an archive entry into a path in fsys.
+func cleanArchivePath(name string) (string, error) {
+	path := pathpkg.Clean("/" + strings.TrimSuffix(name, "/"))[1:]
+	if path == "" {
+		path = "."
+	}
+	if err := validatePath(path); err != nil {
+		return "", wrapErr("AddArchive", name, err)
+	}
+	return path, nil
+}
+
+func newArchiveEntry(name string, mode fs.FileMode, modTime time.Time, uid, gid int) (archiveEntry, error) {
+	path, err := cleanArchivePath(name)
+	if err != nil {
+		return archiveEntry{}, err
+	}
+	return archiveEntry{
+		path:    path,
+		mode:    mode,
+		modTime: modTime,
+		uid:     uid,
+		gid:     gid,
+	}, nil
+}
+
+func (fsys *Fs) addArchiveFile(ent archiveEntry, r io.Reader) (err error) {
+	view := allocate(fsys.allocator, ent.path, ent.mode.Perm(), ent.size)
+	// view is owned by fsys once added.
+	owned := false
+	defer func() {
+		if err != nil && !owned {
+			_ = view.Close()
+		}
+	}()
+
+	f, err := view.Open(os.O_CREATE | os.O_RDWR)
+	if err != nil {
+		return wrapErr("AddArchive", ent.path, err)
+	}
+
+	bytesBuf := bufpool.GetBytes()
+	defer bufpool.PutBytes(bytesBuf)
+
+	_, err = io.CopyBuffer(f, r, *bytesBuf)
+	closeErr := f.Close()
+	if err = errors.Join(err, closeErr); err != nil {
+		return wrapErr("AddArchive", ent.path, err)
+	}
+
+	_, err = fsys.addFile(ent.path, view, fsys.allocator)
+	if err != nil {
+		return wrapErr("AddArchive", ent.path, err)
+	}
+	owned = true
+	return fsys.applyArchiveMeta(ent)
+}
+
+func (fsys *Fs) applyArchiveMeta(ent archiveEntry) error {
+	if err := fsys.Chmod(ent.path, ent.mode.Perm()); err != nil {
+		return err
+	}
+	if err := fsys.Chown(ent.path, ent.uid, ent.gid); err != nil {
+		return err
+	}
+	return fsys.Chtimes(ent.path, time.Time{}, ent.modTime)
+}
@@ -0,0 +1,104 @@
+package synth
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ngicks/go-fsys-helper/aferofs"
+	"github.com/ngicks/go-fsys-helper/aferofs/clock"
+	"gotest.tools/v3/assert"
+)
+
+func TestAddArchive(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for _, h := range []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "./foo/", Mode: 0o555, ModTime: modTime},
+		{Typeflag: tar.TypeReg, Name: "./foo/bar", Mode: 0o640, ModTime: modTime, Size: 3},
+		{Typeflag: tar.TypeReg, Name: "baz/qux", Mode: 0o600, ModTime: modTime, Size: 3},
+		{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "foo/bar", Mode: 0o777, ModTime: modTime},
+		{Typeflag: tar.TypeLink, Name: "hard/qux", Linkname: "./baz/qux", Mode: 0o600, ModTime: modTime},
+	} {
+		assert.NilError(t, tw.WriteHeader(h))
+		if h.Size > 0 {
+			_, err := tw.Write([]byte(h.Name[len(h.Name)-3:]))
+			assert.NilError(t, err)
+		}
+	}
+	assert.NilError(t, tw.Close())
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for _, s := range []struct {
+		name string
+		mode fs.FileMode
+	}{
+		{"foo/", fs.ModeDir | 0o555},
+		{"foo/bar", 0o640},
+		{"baz/qux", 0o600},
+	} {
+		h := &zip.FileHeader{Name: s.name, Modified: modTime}
+		h.SetMode(s.mode)
+		w, err := zw.CreateHeader(h)
+		assert.NilError(t, err)
+		if !s.mode.IsDir() {
+			_, err = w.Write([]byte(s.name[len(s.name)-3:]))
+			assert.NilError(t, err)
+		}
+	}
+	assert.NilError(t, zw.Close())
+
+	for _, tc := range []struct {
+		format ArchiveFormat
+		r      io.Reader
+	}{
+		{ArchiveTar, &tarBuf},
+		{ArchiveZip, bytes.NewReader(zipBuf.Bytes())},
+	} {
+		t.Run(tc.format.String(), func(t *testing.T) {
+			fsys := New(0o022, NewMemFileAllocator(clock.RealWallClock()))
+			assert.NilError(t, fsys.AddArchive(tc.r, tc.format))
+
+			for _, s := range []struct {
+				path string
+				mode fs.FileMode
+			}{
+				{"foo", fs.ModeDir | 0o555},
+				{"foo/bar", 0o640},
+				{"baz/qux", 0o600},
+			} {
+				st, err := fsys.Stat(s.path)
+				assert.NilError(t, err)
+				assert.Equal(t, st.Mode(), s.mode)
+				assert.Assert(t, st.ModTime().Equal(modTime))
+				if !st.IsDir() {
+					bin, err := fs.ReadFile(&aferofs.IoFs{Fs: fsys}, s.path)
+					assert.NilError(t, err)
+					assert.Equal(t, string(bin), s.path[len(s.path)-3:])
+				}
+			}
+			_, err := fsys.Stat("link")
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+
+			if tc.format == ArchiveTar {
+				bin, err := fs.ReadFile(&aferofs.IoFs{Fs: fsys}, "hard/qux")
+				assert.NilError(t, err)
+				assert.Equal(t, string(bin), "qux")
+				tokLink, _ := fsys.ChangeToken("hard/qux")
+				tokFile, _ := fsys.ChangeToken("baz/qux")
+				assert.Equal(t, tokLink, tokFile)
+			}
+		})
+	}
+
+	err := NewNoAlloc(0).AddArchive(bytes.NewReader(zipBuf.Bytes()), ArchiveZip)
+	assert.ErrorIs(t, err, syscall.EROFS)
+}
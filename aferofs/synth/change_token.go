@@ -0,0 +1,52 @@
+package synth
+
+// touch assigns a new change token to ents.
+// The same token is also observed as the token of the whole tree.
+func (fsys *Fs) touch(ents ...*dirent) {
+	token := fsys.token.Add(1)
+	for _, ent := range ents {
+		if ent != nil {
+			ent.token.Store(token)
+		}
+	}
+}
+
+// newFileDirent is like newFileDirent function but
+// the returned dirent's token is updated when its content is changed through opened files.
+// allocator is the one that allocated data, or nil if data is given by the caller.
+// It must be set before the dirent is added to the tree since it is read without locks.
+func (fsys *Fs) newFileDirent(data FileView, path string, allocator FileViewAllocator) (*dirent, error) {
+	ent, err := newFileDirent(data, path)
+	if err != nil {
+		return nil, err
+	}
+	ent.file.allocator = allocator
+	ent.file.onChange = func() { fsys.touch(ent) }
+	return ent, nil
+}
+
+// ChangeToken returns a token of the last change made on the file or directory at path.
+//
+// Tokens are taken from a monotonically increasing counter shared in fsys.
+// The token of a file is updated when its content or metadata is modified through fsys,
+// including writes and truncation through files opened by fsys.
+// The token of a directory is updated when its metadata is modified or
+// its entries are added, removed or renamed.
+// Changes to descendants do not propagate to ancestor directories.
+//
+// Callers can compare tokens to detect changes cheaply, e.g. to use them as ETag.
+// Modification made directly to the backing storage of a [FileView]
+// can not be observed.
+func (fsys *Fs) ChangeToken(path string) (uint64, error) {
+	ent, err := fsys.find(path)
+	if err != nil {
+		return 0, wrapErr("ChangeToken", path, err)
+	}
+	return ent.token.Load(), nil
+}
+
+// TreeChangeToken returns a token of the last change made anywhere in fsys.
+// It is the largest token that [Fs.ChangeToken] may return.
+func (fsys *Fs) TreeChangeToken() uint64 {
+	return fsys.token.Load()
+}
@@ -0,0 +1,57 @@
+package synth
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/ngicks/go-fsys-helper/aferofs/clock"
+	"gotest.tools/v3/assert"
+)
+
+func TestChangeToken(t *testing.T) {
+	fsys := New(0, NewMemFileAllocator(clock.RealWallClock()))
+
+	token := func(path string) uint64 {
+		t.Helper()
+		tok, err := fsys.ChangeToken(path)
+		assert.NilError(t, err)
+		return tok
+	}
+
+	assert.NilError(t, fsys.MkdirAll("foo/bar", fs.ModePerm))
+	f, err := fsys.Create("foo/bar/baz")
+	assert.NilError(t, err)
+	defer f.Close()
+
+	dir, file, tree := token("foo/bar"), token("foo/bar/baz"), fsys.TreeChangeToken()
+	assert.Equal(t, dir, file)
+	assert.Equal(t, file, tree)
+
+	_, err = f.Write([]byte("foo"))
+	assert.NilError(t, err)
+	assert.Assert(t, token("foo/bar/baz") > file)
+	assert.Equal(t, token("foo/bar"), dir)
+	file = token("foo/bar/baz")
+	assert.Equal(t, fsys.TreeChangeToken(), file)
+
+	// reads do not change tokens.
+	_, err = f.ReadAt(make([]byte, 3), 0)
+	assert.NilError(t, err)
+	assert.Equal(t, token("foo/bar/baz"), file)
+
+	assert.NilError(t, fsys.Chmod("foo/bar/baz", 0o644))
+	assert.Assert(t, token("foo/bar/baz") > file)
+
+	foo := token("foo")
+	assert.NilError(t, fsys.Rename("foo/bar/baz", "foo/qux"))
+	assert.Assert(t, token("foo") > foo)
+	assert.Assert(t, token("foo/bar") > dir)
+	assert.Equal(t, token("foo/qux"), fsys.TreeChangeToken())
+
+	dir = token("foo/bar")
+	assert.NilError(t, fsys.Remove("foo/bar"))
+	assert.Assert(t, token("foo") > dir)
+
+	_, err = fsys.ChangeToken("foo/bar")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
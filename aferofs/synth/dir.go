@@ -89,7 +89,7 @@ func (d *dir) Stat(path string) (stat, error) {
 		mode:    fs.ModeDir | d.mode.Perm(),
 		modTime: d.modTime,
 		name:    path,
-		size:    4096,
+		size:    synthBlockSize,
 	}, nil
 }
 
@@ -122,7 +122,7 @@ func (d *dir) AddDirent(u *dirent) (replaced *dirent) {
 		d.dirents.Remove(old)
 	}
 	d.direntMap[u.name] = d.dirents.PushBack(u)
-	return nil
+	return replaced
 }
 
 func (d *dir) RemoveName(name string) {
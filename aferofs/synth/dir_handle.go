@@ -15,14 +15,36 @@ var _ afero.File = (*dirHandle)(nil)
 
 // dirHandle represents an open fd for directory.
 type dirHandle struct {
-	mu   sync.RWMutex
 	dir  *dir
 	name string
-	off  int64
+	pos  *dirPos
+}
+
+// dirPos is the Readdir state, shared among handles duplicated by Dup.
+type dirPos struct {
+	mu  sync.Mutex
+	off int64
 	// This field is used to mimic Go's Readdir behavior.
 	snapshot []fs.FileInfo
 }
 
+func newDirHandle(dir *dir, name string) *dirHandle {
+	return &dirHandle{
+		dir:  dir,
+		name: name,
+		pos:  &dirPos{},
+	}
+}
+
+// Dup returns a new handle sharing the Readdir state with d.
+func (d *dirHandle) Dup() (afero.File, error) {
+	return &dirHandle{
+		dir:  d.dir,
+		name: d.name,
+		pos:  d.pos,
+	}, nil
+}
+
 func (d *dirHandle) Close() error {
 	return nil
 }
@@ -40,10 +62,10 @@ func (d *dirHandle) ReadAt(p []byte, off int64) (n int, err error) {
 }
 
 func (d *dirHandle) Readdir(count int) ([]fs.FileInfo, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	d.pos.mu.Lock()
+	defer d.pos.mu.Unlock()
 
-	if d.snapshot == nil {
+	if d.pos.snapshot == nil {
 		// mimicking Go's behavior of readdir.
 		// On unix, it uses getdents64. It reads dents using 8KiB buffer.
 		// I'm not sure about details, but it does not notice new dir entries are added or removed.
@@ -52,19 +74,19 @@ func (d *dirHandle) Readdir(count int) ([]fs.FileInfo, error) {
 		if err != nil {
 			return []fs.FileInfo{}, err
 		}
-		d.snapshot = snapshot
+		d.pos.snapshot = snapshot
 	}
-	if count <= 0 || count >= len(d.snapshot[d.off:]) {
+	if count <= 0 || count >= len(d.pos.snapshot[d.pos.off:]) {
 		var err error
-		if len(d.snapshot[d.off:]) == 0 && count > 0 {
+		if len(d.pos.snapshot[d.pos.off:]) == 0 && count > 0 {
 			err = io.EOF
 		}
-		snapshots := d.snapshot[d.off:]
-		d.off = int64(len(d.snapshot))
+		snapshots := d.pos.snapshot[d.pos.off:]
+		d.pos.off = int64(len(d.pos.snapshot))
 		return snapshots, err
 	}
-	ret := d.snapshot[d.off : int(d.off)+count]
-	d.off += int64(count)
+	ret := d.pos.snapshot[d.pos.off : int(d.pos.off)+count]
+	d.pos.off += int64(count)
 	return ret, nil
 }
 
@@ -81,12 +103,12 @@ func (d *dirHandle) Readdirnames(n int) ([]string, error) {
 }
 
 func (d *dirHandle) Seek(offset int64, whence int) (int64, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	d.pos.mu.Lock()
+	defer d.pos.mu.Unlock()
 
 	// reset anyway
-	d.snapshot = nil
-	d.off = 0
+	d.pos.snapshot = nil
+	d.pos.off = 0
 
 	switch whence {
 	default:
@@ -3,11 +3,15 @@ package synth
 import (
 	"io/fs"
 	pathPkg "path"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
 // A readonly struct. no locks.
+// Fields are never reassigned once the dirent is added to a dir;
+// renaming creates a new dirent by [dirent.withName].
+// The token it points to is updated atomically.
 type dirent struct {
 	// base name of dirent.
 	name string
@@ -15,21 +19,42 @@ type dirent struct {
 	dir *dir
 	// non-nil if is a file.
 	file *virtualFileData
+	// token of the last change. See [Fs.ChangeToken].
+	// Hard links share the token with the linked dirent.
+	token *atomic.Uint64
+}
+
+// withName returns a copy of d named name.
+// The copy shares the dir, file and token with d.
+func (d *dirent) withName(name string) *dirent {
+	return &dirent{
+		name:  name,
+		dir:   d.dir,
+		file:  d.file,
+		token: d.token,
+	}
 }
 
 func newDirDirent(name string, mode fs.FileMode, modTime time.Time, dirents ...*dirent) *dirent {
 	return &dirent{
-		name: name,
-		dir:  newDirData(mode, modTime, dirents...),
+		name:  name,
+		dir:   newDirData(mode, modTime, dirents...),
+		token: new(atomic.Uint64),
 	}
 }
 
 func newFileDirent(data FileView, path string) (*dirent, error) {
-	vf, err := newVirtualFileData(data, pathPkg.Base(path))
+	vf, err := newVirtualFileData(data)
 	if err != nil {
 		return nil, err
 	}
-	return &dirent{name: pathPkg.Base(path), file: vf}, nil
+	return &dirent{name: pathPkg.Base(path), file: vf, token: new(atomic.Uint64)}, nil
+}
+
+// newLinkDirent returns a new dirent named name which is a hard link to the file dirent d.
+func (d *dirent) newLinkDirent(name string) *dirent {
+	d.file.link()
+	return &dirent{name: name, file: d.file, token: d.token}
 }
 
 func (d *dirent) IsSearchableDir() error {
@@ -94,7 +119,7 @@ func (d *dirent) IsFile() bool {
 
 func (d *dirent) IsReadable() error {
 	if !d.hasPerm(0o4) {
-		return syscall.EPERM
+		return syscall.EACCES
 	}
 	return nil
 }
@@ -119,7 +144,7 @@ func (d *dirent) stat() (fs.FileInfo, error) {
 	if d.dir != nil {
 		return d.dir.Stat(d.name)
 	} else {
-		return d.file.Stat()
+		return d.file.Stat(d.name)
 	}
 }
 
@@ -0,0 +1,69 @@
+package synth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ngicks/go-fsys-helper/aferofs/internal/closable"
+	"github.com/spf13/afero"
+)
+
+// Dupper is implemented by files that can be duplicated.
+//
+// Dup returns a new file sharing the file offset with the receiver, like dup(2).
+// Closing either one does not affect the other.
+//
+// Files opened by FileView may implement Dupper to support [Dup].
+type Dupper interface {
+	Dup() (afero.File, error)
+}
+
+// Dup duplicates f which is opened by *Fs, like dup(2).
+//
+// Files returned from separate Open or OpenFile calls have independent offsets.
+// In contrast, the file returned from Dup shares the offset with f.
+// For directories, the Readdir state is shared as well,
+// which is reset by calling Seek on either one.
+// The file stays open until both f and the returned file are closed.
+//
+// Dup returns an error wrapping errors.ErrUnsupported if f is not opened by *Fs
+// or files opened by its FileView do not implement [Dupper].
+func Dup(f afero.File) (afero.File, error) {
+	c, ok := f.(*closable.Closable[afero.File])
+	if !ok {
+		return nil, wrapErr("dup", f.Name(), fmt.Errorf("%w: not opened by *synth.Fs", errors.ErrUnsupported))
+	}
+	inner, err := c.Inner()
+	if err != nil {
+		return nil, wrapErr("dup", f.Name(), err)
+	}
+
+	var duped afero.File
+	switch x := inner.(type) {
+	case *dirHandle:
+		duped, err = x.Dup()
+	case *virtualFile:
+		duped, err = x.dup()
+	default:
+		err = errors.ErrUnsupported
+	}
+	if err != nil {
+		return nil, wrapErr("dup", f.Name(), err)
+	}
+	return newFd(duped), nil
+}
+
+func (v *virtualFile) dup() (afero.File, error) {
+	d, ok := v.File.(Dupper)
+	if !ok {
+		return nil, fmt.Errorf("%w: file opened by FileView does not implement Dupper", errors.ErrUnsupported)
+	}
+	f, err := d.Dup()
+	if err != nil {
+		return nil, err
+	}
+	v.meta.mu.Lock()
+	v.meta.refs++
+	v.meta.mu.Unlock()
+	return &virtualFile{meta: v.meta, name: v.name, File: f}, nil
+}
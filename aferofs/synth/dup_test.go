@@ -0,0 +1,127 @@
+package synth
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/ngicks/go-fsys-helper/aferofs/clock"
+	"github.com/spf13/afero"
+	"gotest.tools/v3/assert"
+)
+
+func TestHandleOffsets(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		newFsys func(t *testing.T) afero.Fs
+	}{
+		{"synth", func(t *testing.T) afero.Fs { return New(0, NewMemFileAllocator(clock.RealWallClock())) }},
+		{"os", func(t *testing.T) afero.Fs { return afero.NewBasePathFs(afero.NewOsFs(), t.TempDir()) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := tc.newFsys(t)
+
+			// separate opens have independent offsets.
+			f1, err := fsys.Create("foo")
+			assert.NilError(t, err)
+			defer f1.Close()
+			_, err = f1.Write([]byte("foobar"))
+			assert.NilError(t, err)
+
+			f2, err := fsys.Open("foo")
+			assert.NilError(t, err)
+			defer f2.Close()
+			var buf [3]byte
+			_, err = io.ReadFull(f2, buf[:])
+			assert.NilError(t, err)
+			assert.Equal(t, string(buf[:]), "foo")
+
+			off, err := f1.Seek(0, io.SeekCurrent)
+			assert.NilError(t, err)
+			assert.Equal(t, off, int64(6))
+			off, err = f2.Seek(0, io.SeekCurrent)
+			assert.NilError(t, err)
+			assert.Equal(t, off, int64(3))
+
+			// Readdir paging state is reset by Seek(0, io.SeekStart).
+			for _, name := range []string{"dir", "dir/a", "dir/b", "dir/c"} {
+				assert.NilError(t, fsys.Mkdir(name, fs.ModePerm))
+			}
+			d, err := fsys.Open("dir")
+			assert.NilError(t, err)
+			defer d.Close()
+			names, err := d.Readdirnames(2)
+			assert.NilError(t, err)
+			assert.Equal(t, len(names), 2)
+			names, err = d.Readdirnames(-1)
+			assert.NilError(t, err)
+			assert.Equal(t, len(names), 1)
+			_, err = d.Readdirnames(1)
+			assert.ErrorIs(t, err, io.EOF)
+
+			_, err = d.Seek(0, io.SeekStart)
+			assert.NilError(t, err)
+			names, err = d.Readdirnames(-1)
+			assert.NilError(t, err)
+			assert.Equal(t, len(names), 3)
+		})
+	}
+}
+
+func TestDup(t *testing.T) {
+	fsys := New(0, NewMemFileAllocator(clock.RealWallClock()))
+
+	f, err := fsys.Create("foo")
+	assert.NilError(t, err)
+	_, err = f.Write([]byte("foobar"))
+	assert.NilError(t, err)
+	_, err = f.Seek(0, io.SeekStart)
+	assert.NilError(t, err)
+
+	duped, err := Dup(f)
+	assert.NilError(t, err)
+
+	var buf [3]byte
+	_, err = io.ReadFull(f, buf[:])
+	assert.NilError(t, err)
+	_, err = io.ReadFull(duped, buf[:])
+	assert.NilError(t, err)
+	assert.Equal(t, string(buf[:]), "bar")
+
+	// closing one does not affect the other.
+	assert.NilError(t, f.Close())
+	off, err := duped.Seek(0, io.SeekCurrent)
+	assert.NilError(t, err)
+	assert.Equal(t, off, int64(6))
+	assert.NilError(t, duped.Close())
+	_, err = Dup(duped)
+	assert.ErrorIs(t, err, fs.ErrClosed)
+
+	assert.NilError(t, fsys.MkdirAll("dir/a", fs.ModePerm))
+	assert.NilError(t, fsys.Mkdir("dir/b", fs.ModePerm))
+	d, err := fsys.Open("dir")
+	assert.NilError(t, err)
+	defer d.Close()
+	dd, err := Dup(d)
+	assert.NilError(t, err)
+	defer dd.Close()
+	names, err := d.Readdirnames(1)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names, []string{"a"})
+	names, err = dd.Readdirnames(1)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names, []string{"b"})
+	_, err = dd.Seek(0, io.SeekStart)
+	assert.NilError(t, err)
+	names, err = d.Readdirnames(-1)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, names, []string{"a", "b"})
+
+	osf, err := os.Open(t.TempDir())
+	assert.NilError(t, err)
+	defer osf.Close()
+	_, err = Dup(osf)
+	assert.Assert(t, errors.Is(err, errors.ErrUnsupported))
+}
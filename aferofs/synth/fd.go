@@ -11,12 +11,9 @@ func newFd(t afero.File) *closable.Closable[afero.File] {
 
 func newOpenHandle(path string, flag int, d *dirent) (*closable.Closable[afero.File], error) {
 	if d.dir != nil {
-		return newFd(&dirHandle{
-			dir:  d.dir,
-			name: path,
-		}), nil
+		return newFd(newDirHandle(d.dir, path)), nil
 	} else {
-		f, err := d.file.Open(flag)
+		f, err := d.file.Open(d.name, flag)
 		if err != nil {
 			return nil, err
 		}
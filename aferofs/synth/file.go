@@ -2,8 +2,11 @@ package synth
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -13,50 +16,120 @@ import (
 var _ afero.File = (*virtualFile)(nil)
 
 type virtualFile struct {
-	meta *virtualFileData
+	meta   *virtualFileData
+	name   string
+	closed atomic.Bool
 	afero.File
 }
 
+func (v *virtualFile) Close() error {
+	if !v.closed.CompareAndSwap(false, true) {
+		return fs.ErrClosed
+	}
+	err := v.File.Close()
+	if relErr := v.meta.unref(); relErr != nil {
+		err = errors.Join(err, fmt.Errorf("%w: %w", ErrClosedWithError, relErr))
+	}
+	return err
+}
+
 func (v *virtualFile) Name() string {
-	return v.meta.name
+	return v.name
 }
 
 func (v *virtualFile) Stat() (fs.FileInfo, error) {
-	return v.meta.StatFile(v.File)
+	return v.meta.StatFile(v.File, v.name)
+}
+
+func (v *virtualFile) Truncate(size int64) error {
+	err := v.File.Truncate(size)
+	if err == nil {
+		v.meta.notifyChange()
+		v.meta.notifyResize(size, false)
+	}
+	return err
+}
+
+func (v *virtualFile) Write(p []byte) (n int, err error) {
+	n, err = v.File.Write(p)
+	if n > 0 {
+		v.meta.notifyChange()
+		v.notifyWritten()
+	}
+	return n, err
+}
+
+func (v *virtualFile) WriteAt(p []byte, off int64) (n int, err error) {
+	n, err = v.File.WriteAt(p, off)
+	if n > 0 {
+		v.meta.notifyChange()
+		v.meta.notifyResize(off+int64(n), true)
+	}
+	return n, err
+}
+
+func (v *virtualFile) WriteString(s string) (ret int, err error) {
+	ret, err = v.File.WriteString(s)
+	if ret > 0 {
+		v.meta.notifyChange()
+		v.notifyWritten()
+	}
+	return ret, err
+}
+
+// notifyWritten notifies the size tracker, if any, of the end of the last sequential write.
+func (v *virtualFile) notifyWritten() {
+	if !v.meta.tracksSize() {
+		return
+	}
+	end, err := v.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	v.meta.notifyResize(end, true)
 }
 
 type virtualFileData struct {
 	file FileView
+	// allocator is non-nil if file is allocated by it.
+	allocator FileViewAllocator
+	// onChange, if non-nil, is called when the content is modified through opened files.
+	onChange func()
 
 	mu          sync.RWMutex
 	initialized bool
-	name        string
 	mode        fs.FileMode
 	uid, gid    int
 	modTime     time.Time
+	size        int64 // last known size, only maintained for FileViewSizeTracker.
+	nlink       int   // number of names referring this.
+	refs        int   // number of files opened and not yet closed.
+	unlinked    bool  // no longer referred by any name.
+	released    bool
 }
 
-func newVirtualFileData(f FileView, name string) (*virtualFileData, error) {
+func newVirtualFileData(f FileView) (*virtualFileData, error) {
+	vfd := &virtualFileData{
+		file:  f,
+		nlink: 1,
+	}
 	s, err := f.Stat()
-	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			return &virtualFileData{
-				file: f,
-				name: name,
-			}, nil
-		}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// initialized lazily by Open.
+	case err != nil:
 		return nil, err
-	}
-	if s.IsDir() {
+	case s.IsDir():
 		return nil, syscall.EISDIR
+	default:
+		if err := vfd.init(s, nil); err != nil {
+			return nil, err
+		}
 	}
-	vfd := &virtualFileData{
-		file: f,
-		name: name,
-	}
-	err = vfd.init(s, nil)
-	if err != nil {
-		return nil, err
+	if s, ok := f.(*sharedView); ok {
+		if err := s.acquire(); err != nil {
+			return nil, err
+		}
 	}
 	return vfd, nil
 }
@@ -87,46 +160,118 @@ func (v *virtualFileData) init(s fs.FileInfo, f afero.File) error {
 
 	v.mode = s.Mode()
 	v.modTime = s.ModTime()
+	v.size = s.Size()
 
 	v.initialized = true
 
 	return nil
 }
 
+func (v *virtualFileData) notifyChange() {
+	if v.onChange != nil {
+		v.onChange()
+	}
+}
+
+// link notifies that v is referred by one more name.
+func (v *virtualFileData) link() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.nlink++
+}
+
+// notifyClose notifies that v is no longer referred by one of its names.
+// Once no name refers v, the FileView is closed immediately if no file is open,
+// otherwise when the last one is closed.
 func (v *virtualFileData) notifyClose() error {
-	return v.file.Close()
+	v.mu.Lock()
+	v.nlink--
+	if v.nlink > 0 {
+		v.mu.Unlock()
+		return nil
+	}
+	v.unlinked = true
+	release := v.shouldRelease()
+	v.mu.Unlock()
+	if release {
+		return v.release()
+	}
+	return nil
+}
+
+func (v *virtualFileData) unref() error {
+	v.mu.Lock()
+	v.refs--
+	release := v.shouldRelease()
+	v.mu.Unlock()
+	if release {
+		return v.release()
+	}
+	return nil
+}
+
+func (v *virtualFileData) shouldRelease() bool {
+	if v.unlinked && v.refs <= 0 && !v.released {
+		v.released = true
+		return true
+	}
+	return false
 }
 
-func (v *virtualFileData) Open(flag int) (afero.File, error) {
+func (v *virtualFileData) release() error {
+	view := v.file
+	var err error
+	if s, ok := view.(*sharedView); ok {
+		var closed bool
+		closed, err = s.release()
+		if !closed {
+			// still referred by other *Fs or other paths.
+			return nil
+		}
+		view = s.FileView
+	} else {
+		err = view.Close()
+	}
+	if r, ok := v.allocator.(FileViewReleaser); ok {
+		r.Release(view)
+	}
+	return err
+}
+
+func (v *virtualFileData) Open(name string, flag int) (afero.File, error) {
 	f, err := v.file.Open(flag)
 	if err != nil {
 		return nil, err
 	}
 	err = v.init(nil, f)
 	if err != nil {
+		_ = f.Close()
 		return nil, err
 	}
-	return &virtualFile{meta: v, File: f}, nil
+	v.mu.Lock()
+	v.refs++
+	v.mu.Unlock()
+	return &virtualFile{meta: v, name: name, File: f}, nil
 }
 
-func (v *virtualFileData) Stat() (fs.FileInfo, error) {
+func (v *virtualFileData) Stat(name string) (fs.FileInfo, error) {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
 	s, err := v.file.Stat()
 	if err != nil {
 		return nil, err
 	}
-	return stat{v.mode, v.modTime, v.name, s.Size()}, nil
+	return stat{v.mode, v.modTime, name, s.Size()}, nil
 }
 
-func (v *virtualFileData) StatFile(f afero.File) (fs.FileInfo, error) {
+func (v *virtualFileData) StatFile(f afero.File, name string) (fs.FileInfo, error) {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
 	s, err := f.Stat()
 	if err != nil {
 		return nil, err
 	}
-	return stat{v.mode, v.modTime, v.name, s.Size()}, nil
+	return stat{v.mode, v.modTime, name, s.Size()}, nil
 }
 
 func (v *virtualFileData) notifyRename(newname string) {
@@ -134,7 +279,40 @@ func (v *virtualFileData) notifyRename(newname string) {
 }
 
 func (v *virtualFileData) Truncate(size int64) error {
-	return v.file.Truncate(size)
+	err := v.file.Truncate(size)
+	if err == nil {
+		v.notifyResize(size, false)
+	}
+	return err
+}
+
+func (v *virtualFileData) tracksSize() bool {
+	_, ok := v.allocator.(FileViewSizeTracker)
+	return ok
+}
+
+// notifyResize notifies the size tracker, if any, that the size is now newSize.
+// If growOnly is true, newSize smaller than the last known size is ignored,
+// since writes never shrink files.
+func (v *virtualFileData) notifyResize(newSize int64, growOnly bool) {
+	tracker, ok := v.allocator.(FileViewSizeTracker)
+	if !ok {
+		return
+	}
+	v.mu.Lock()
+	oldSize := v.size
+	if newSize == oldSize || (growOnly && newSize < oldSize) {
+		v.mu.Unlock()
+		return
+	}
+	v.size = newSize
+	v.mu.Unlock()
+
+	if newSize > oldSize {
+		tracker.Grow(v.file, oldSize, newSize)
+	} else {
+		tracker.Shrink(v.file, oldSize, newSize)
+	}
 }
 
 func (v *virtualFileData) Chmod(mode fs.FileMode) {
@@ -0,0 +1,230 @@
+package synth
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/ngicks/go-fsys-helper/aferofs"
+	"github.com/ngicks/go-fsys-helper/aferofs/clock"
+	"gotest.tools/v3/assert"
+)
+
+type releaseRecorder struct {
+	*MemFileAllocator
+	released []FileView
+}
+
+func (r *releaseRecorder) Release(view FileView) {
+	r.released = append(r.released, view)
+}
+
+func TestRemoveWhileOpen(t *testing.T) {
+	alloc := &releaseRecorder{MemFileAllocator: NewMemFileAllocator(clock.RealWallClock())}
+	fsys := New(0, alloc)
+
+	f1, err := fsys.Create("foo")
+	assert.NilError(t, err)
+	_, err = f1.Write([]byte("foobar"))
+	assert.NilError(t, err)
+	f2, err := fsys.Open("foo")
+	assert.NilError(t, err)
+
+	assert.NilError(t, fsys.Remove("foo"))
+	_, err = fsys.Stat("foo")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// content is still available for opened files.
+	assert.NilError(t, f1.Close())
+	assert.Equal(t, len(alloc.released), 0)
+	bin, err := io.ReadAll(f2)
+	assert.NilError(t, err)
+	assert.Equal(t, string(bin), "foobar")
+
+	assert.NilError(t, f2.Close())
+	assert.Equal(t, len(alloc.released), 1)
+	assert.Equal(t, alloc.Stats().Files, 0)
+
+	// released only once.
+	assert.ErrorIs(t, f2.Close(), os.ErrClosed)
+	assert.Equal(t, len(alloc.released), 1)
+
+	// a file replaced by rename is released too.
+	for _, name := range []string{"bar", "baz"} {
+		f, err := fsys.Create(name)
+		assert.NilError(t, err)
+		assert.NilError(t, f.Close())
+	}
+	assert.NilError(t, fsys.Rename("bar", "baz"))
+	assert.Equal(t, len(alloc.released), 2)
+}
+
+type sizeRecorder struct {
+	*MemFileAllocator
+	hints   []int64
+	changes [][2]int64
+}
+
+func (r *sizeRecorder) AllocateSize(path string, perm fs.FileMode, sizeHint int64) FileView {
+	r.hints = append(r.hints, sizeHint)
+	return r.MemFileAllocator.AllocateSize(path, perm, sizeHint)
+}
+
+func (r *sizeRecorder) Grow(view FileView, oldSize, newSize int64) {
+	r.changes = append(r.changes, [2]int64{oldSize, newSize})
+}
+
+func (r *sizeRecorder) Shrink(view FileView, oldSize, newSize int64) {
+	r.changes = append(r.changes, [2]int64{oldSize, newSize})
+}
+
+func TestSizeTracker(t *testing.T) {
+	alloc := &sizeRecorder{MemFileAllocator: NewMemFileAllocator(clock.RealWallClock())}
+	fsys := New(0, alloc)
+
+	f, err := fsys.Create("foo")
+	assert.NilError(t, err)
+	_, err = f.Write([]byte("foo"))
+	assert.NilError(t, err)
+	_, err = f.WriteAt([]byte("bar"), 10)
+	assert.NilError(t, err)
+	// overwriting does not grow.
+	_, err = f.WriteAt([]byte("baz"), 0)
+	assert.NilError(t, err)
+	assert.NilError(t, f.Truncate(5))
+	assert.NilError(t, f.Close())
+
+	f, err = fsys.OpenFile("foo", os.O_RDWR|os.O_TRUNC, 0)
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+
+	assert.DeepEqual(t, alloc.changes, [][2]int64{{0, 3}, {3, 13}, {13, 5}, {5, 0}})
+
+	f, err = fsys.Create("bar")
+	assert.NilError(t, err)
+	_, err = f.Write(make([]byte, 100))
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+	assert.NilError(t, fsys.Reallocate("bar", alloc))
+	assert.DeepEqual(t, alloc.hints, []int64{100})
+}
+
+func TestAddLink(t *testing.T) {
+	alloc := &releaseRecorder{MemFileAllocator: NewMemFileAllocator(clock.RealWallClock())}
+	fsys := New(0, alloc)
+
+	f, err := fsys.Create("foo")
+	assert.NilError(t, err)
+	_, err = f.Write([]byte("foo"))
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+
+	assert.NilError(t, fsys.AddLink("bar/baz", "foo"))
+	assert.NilError(t, fsys.AddLink("bar/baz", "foo")) // no-op
+	assert.ErrorIs(t, fsys.AddLink("qux", "bar"), syscall.EPERM)
+	assert.ErrorIs(t, fsys.AddLink("qux", "nonexistent"), fs.ErrNotExist)
+
+	s, err := fsys.Stat("bar/baz")
+	assert.NilError(t, err)
+	assert.Equal(t, s.Name(), "baz")
+	assert.Equal(t, s.Size(), int64(3))
+
+	f, err = fsys.OpenFile("bar/baz", os.O_WRONLY|os.O_APPEND, 0)
+	assert.NilError(t, err)
+	_, err = f.Write([]byte("bar"))
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+
+	bin, err := fs.ReadFile(&aferofs.IoFs{Fs: fsys}, "foo")
+	assert.NilError(t, err)
+	assert.Equal(t, string(bin), "foobar")
+
+	tokFoo, _ := fsys.ChangeToken("foo")
+	tokBaz, _ := fsys.ChangeToken("bar/baz")
+	assert.Equal(t, tokFoo, tokBaz)
+
+	assert.NilError(t, fsys.Chmod("foo", 0o600))
+	s, err = fsys.Stat("bar/baz")
+	assert.NilError(t, err)
+	assert.Equal(t, s.Mode().Perm(), fs.FileMode(0o600))
+
+	// renaming onto another link is a no-op.
+	assert.NilError(t, fsys.Rename("foo", "bar/baz"))
+	_, err = fsys.Stat("foo")
+	assert.NilError(t, err)
+
+	assert.NilError(t, fsys.Remove("foo"))
+	assert.Equal(t, len(alloc.released), 0)
+	assert.NilError(t, fsys.Remove("bar/baz"))
+	assert.Equal(t, len(alloc.released), 1)
+}
+
+func TestReallocate_link(t *testing.T) {
+	alloc := &releaseRecorder{MemFileAllocator: NewMemFileAllocator(clock.RealWallClock())}
+	fsys := New(0, alloc)
+
+	f, err := fsys.Create("foo")
+	assert.NilError(t, err)
+	_, err = f.Write([]byte("hello"))
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+
+	assert.NilError(t, fsys.AddLink("bar", "foo"))
+	assert.NilError(t, fsys.Reallocate("foo", alloc))
+	assert.Equal(t, len(alloc.released), 0)
+
+	iofs := &aferofs.IoFs{Fs: fsys}
+	for _, name := range []string{"foo", "bar"} {
+		bin, err := fs.ReadFile(iofs, name)
+		assert.NilError(t, err)
+		assert.Equal(t, string(bin), "hello", "name = %s", name)
+	}
+
+	assert.NilError(t, fsys.Remove("bar"))
+	assert.Equal(t, len(alloc.released), 1)
+}
+
+type closeCountingView struct {
+	FileView
+	closed int
+}
+
+func (v *closeCountingView) Close() error {
+	v.closed++
+	return v.FileView.Close()
+}
+
+func TestSharedFileView(t *testing.T) {
+	inner, err := NewFsFileView(randomBytes, "testdata/random0")
+	assert.NilError(t, err)
+	view := &closeCountingView{FileView: inner}
+	shared := Share(view)
+
+	fsys1 := NewNoAlloc(0)
+	fsys2 := NewNoAlloc(0)
+
+	assert.NilError(t, fsys1.AddFile("foo", shared))
+	assert.NilError(t, fsys1.AddFile("bar", shared))
+	assert.NilError(t, fsys2.AddFile("foo", shared))
+
+	f, err := fsys2.Open("foo")
+	assert.NilError(t, err)
+
+	assert.NilError(t, fsys1.Remove("foo"))
+	assert.NilError(t, fsys1.Remove("bar"))
+	assert.Equal(t, view.closed, 0)
+
+	assert.NilError(t, fsys2.Remove("foo"))
+	assert.Equal(t, view.closed, 0)
+	_, err = io.ReadAll(f)
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+	assert.Equal(t, view.closed, 1)
+
+	// closed views can not be added again.
+	assert.ErrorIs(t, fsys1.AddFile("baz", shared), fs.ErrClosed)
+	_, err = fsys1.Stat("baz")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
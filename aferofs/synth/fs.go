@@ -9,6 +9,7 @@ import (
 	"path"
 	pathpkg "path"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -35,6 +36,7 @@ type Fs struct {
 	clock     clock.WallClock
 	root      *dirent
 	allocator FileViewAllocator
+	token     atomic.Uint64
 }
 
 func newFsys(umask fs.FileMode, allocator FileViewAllocator, opt ...FsOption) *Fs {
@@ -46,7 +48,7 @@ func newFsys(umask fs.FileMode, allocator FileViewAllocator, opt ...FsOption) *F
 	for _, o := range opt {
 		o.apply(fsys)
 	}
-	fsys.root = &dirent{name: ".", dir: newDirData(fs.ModePerm, fsys.clock.Now())}
+	fsys.root = newDirDirent(".", fs.ModePerm, fsys.clock.Now())
 	return fsys
 }
 
@@ -92,7 +94,7 @@ func validatePath(path string) error {
 		// Many implementations also uses UTF-8 for path encoding.
 		return syscall.ENAMETOOLONG
 	}
-	return nil
+	return validatePathPlatform(path)
 }
 
 func (fsys *Fs) findParent(path string) (*dirent, error) {
@@ -144,20 +146,6 @@ func (fsys *Fs) find(path string) (*dirent, error) {
 	return ent, nil
 }
 
-func (fsys *Fs) findWritableDir(path string) (*dirent, error) {
-	dirent, err := fsys.find(path)
-	if err != nil {
-		return nil, err
-	}
-	if err := dirent.IsDirErr(); err != nil {
-		return nil, err
-	}
-	if !dirent.hasPerm(0o2) {
-		return nil, syscall.EACCES
-	}
-	return dirent, nil
-}
-
 func permErr(dirent *dirent, perm int) error {
 	if !dirent.hasPerm(perm) {
 		return syscall.EACCES
@@ -184,6 +172,7 @@ func (fsys *Fs) Chmod(name string, mode fs.FileMode) error {
 	}
 	// Fs owns all files inside. So no permission checked.
 	ent.chmod(mode)
+	fsys.touch(ent)
 	return nil
 }
 
@@ -195,6 +184,7 @@ func (fsys *Fs) Chown(name string, uid int, gid int) error {
 		return wrapErr("chown", name, err)
 	}
 	ent.chown(uid, gid)
+	fsys.touch(ent)
 	return nil
 }
 
@@ -204,6 +194,7 @@ func (fsys *Fs) Chtimes(name string, atime time.Time, mtime time.Time) error {
 		return wrapErr("chtimes", name, err)
 	}
 	ent.chtimes(atime, mtime)
+	fsys.touch(ent)
 	return nil
 }
 
@@ -217,30 +208,34 @@ func (fsys *Fs) Mkdir(name string, perm fs.FileMode) error {
 }
 
 func (fys *Fs) mkdir(name string, perm fs.FileMode) error {
-	parent, err := fys.findWritableDir(path.Dir(name))
-	if err != nil {
-		return err
-	}
-
 	basename := pathpkg.Base(name)
 	if basename == "." {
 		// The root dir always exists, cannot be removed.
 		return syscall.EEXIST
 	}
 
+	// Like linux, the order of checks is:
+	// search permission of the parent, existence, then write permission of the parent.
+	parent, err := fys.find(path.Dir(name))
+	if err != nil {
+		return err
+	}
+	if err := parent.IsSearchableDir(); err != nil {
+		return err
+	}
+
 	_, ok := parent.lookup(basename)
 	if ok {
 		return syscall.EEXIST
 	}
 
-	if !parent.hasPerm(0o1) {
-		return syscall.EACCES
-	}
 	if !parent.hasPerm(0o2) {
-		return syscall.EPERM
+		return syscall.EACCES
 	}
 
-	parent.addDirent(newDirDirent(basename, fys.maskPerm(perm), fys.clock.Now()))
+	child := newDirDirent(basename, fys.maskPerm(perm), fys.clock.Now())
+	parent.addDirent(child)
+	fys.touch(parent, child)
 
 	return nil
 }
@@ -285,13 +280,26 @@ func (fsys *Fs) mkdirAll(path string, perm fs.FileMode) error {
 
 		child, ok = parent.lookup(top)
 		if !ok {
+			// Like os.MkdirAll, perm is applied only to directories created here.
+			if !parent.hasPerm(0o2) {
+				return wrapErr("mkdir", org[:currentPathIdx], syscall.EACCES)
+			}
 			child = newDirDirent(top, fsys.maskPerm(perm), fsys.clock.Now())
 			parent.addDirent(child)
+			fsys.touch(parent, child)
 		}
 
-		if err := child.IsSearchableDir(); err != nil {
+		// A file in the middle of path, or at the end of it, is reported as ENOTDIR
+		// with the path to the file.
+		if err := child.IsDirErr(); err != nil {
 			return wrapErr("mkdir", org[:currentPathIdx], err)
 		}
+		if len(path) > 0 {
+			// Only directories to be traversed need search permission.
+			if err := child.IsSearchableDir(); err != nil {
+				return wrapErr("mkdir", org[:currentPathIdx], err)
+			}
+		}
 		parent = child
 	}
 
@@ -317,23 +325,24 @@ func (fsys *Fs) openFile(name string, flag int, perm fs.FileMode) (afero.File, e
 		return nil, err
 	}
 
+	var (
+		ent *dirent
+		ok  bool
+	)
 	basename := pathpkg.Base(name)
 	if basename == "." {
-		return newOpenHandle(name, flag, fsys.root)
+		ent, ok = fsys.root, true
+	} else {
+		ent, ok = parent.lookup(basename)
 	}
 
-	ent, ok := parent.lookup(basename)
 	if ok {
-		if flag&os.O_EXCL != 0 {
+		// O_EXCL without O_CREATE is undefined in posix; linux ignores it.
+		if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
 			return nil, syscall.EEXIST
 		}
-		targetPerm := flagPerm(flag)
-		if !ent.hasPerm(targetPerm) {
-			return nil, syscall.EACCES
-		}
-		if ent.IsDir() &&
-			(flagWritable(flag) || flag&os.O_TRUNC != 0) {
-			return nil, syscall.EISDIR
+		if err := checkOpenFlag(ent, flag); err != nil {
+			return nil, err
 		}
 		if flag&os.O_TRUNC != 0 {
 			// https://man7.org/linux/man-pages/man2/open.2.html#VERSIONS
@@ -344,6 +353,7 @@ func (fsys *Fs) openFile(name string, flag int, perm fs.FileMode) (afero.File, e
 			if err != nil {
 				return nil, err
 			}
+			fsys.touch(ent)
 		}
 		return newOpenHandle(name, flag, ent)
 	}
@@ -360,25 +370,49 @@ func (fsys *Fs) openFile(name string, flag int, perm fs.FileMode) (afero.File, e
 		return nil, syscall.EROFS
 	}
 
-	data := fsys.allocator.Allocate(name, perm)
-	f, err := newFileDirent(data, name)
+	// The newly created file is opened with flag regardless of perm,
+	// just like open(2) does.
+	data := fsys.allocator.Allocate(name, fsys.maskPerm(perm))
+	f, err := fsys.newFileDirent(data, name, fsys.allocator)
 	if err != nil {
 		return nil, err
 	}
 	opened, err := newOpenHandle(name, flag, f)
 	if err != nil {
+		_ = f.notifyClose()
 		return nil, err
 	}
 	parent.addDirent(f)
+	fsys.touch(parent, f)
 	return opened, nil
 }
 
+// checkOpenFlag checks whether the existing ent can be opened with flag.
+// Directories can not be opened for writing, truncation or creation.
+// O_TRUNC requires write permission even if combined with os.O_RDONLY.
+func checkOpenFlag(ent *dirent, flag int) error {
+	if ent.IsDir() && (flagWritable(flag) || flag&(os.O_TRUNC|os.O_CREATE) != 0) {
+		return syscall.EISDIR
+	}
+	targetPerm := flagPerm(flag)
+	if flag&os.O_TRUNC != 0 {
+		targetPerm |= 0o2
+	}
+	if !ent.hasPerm(targetPerm) {
+		return syscall.EACCES
+	}
+	return nil
+}
+
 func (fsys *Fs) Remove(name string) error {
 	parent, err := fsys.findParent(name)
 	if err != nil {
 		return wrapErr("remove", name, err)
 	}
 	err = removeFromParent(parent, name)
+	if err == nil || errors.Is(err, ErrClosedWithError) {
+		fsys.touch(parent)
+	}
 	if err != nil {
 		return wrapErr("remove", name, err)
 	}
@@ -388,15 +422,21 @@ func (fsys *Fs) Remove(name string) error {
 func removeFromParent(parent *dirent, name string) error {
 	basename := pathpkg.Base(name)
 	if basename == "." {
-		return syscall.EPERM
+		// rmdir(2) returns EINVAL for a path whose last component is "."
+		return syscall.EINVAL
 	}
-	if !parent.hasPerm(0o3) {
+	// Like linux, the order of checks is:
+	// search permission of the parent, existence, write permission of the parent, then emptiness.
+	if !parent.hasPerm(0o1) {
 		return syscall.EACCES
 	}
 	ent, ok := parent.lookup(basename)
 	if !ok {
 		return syscall.ENOENT
 	}
+	if !parent.hasPerm(0o2) {
+		return syscall.EACCES
+	}
 	if ent.IsDir() && ent.len() > 0 {
 		return syscall.ENOTEMPTY
 	}
@@ -419,8 +459,8 @@ func (fsys *Fs) removeAll(name string) error {
 	}
 
 	if name == "." {
-		// can't remove
-		return syscall.EACCES
+		// can't remove. os.RemoveAll returns EINVAL as well.
+		return syscall.EINVAL
 	}
 
 	err := fsys.Remove(name)
@@ -434,6 +474,7 @@ func (fsys *Fs) removeAll(name string) error {
 	}
 
 	errorPath, err := removeAllFrom(parent, pathpkg.Base(name))
+	fsys.touch(parent)
 	if err != nil {
 		return &fs.PathError{Op: "remove", Path: errorPath, Err: err}
 	}
@@ -533,6 +574,10 @@ func (fsys *Fs) rename(oldname string, newname string) error {
 	}
 
 	if newTarget != nil {
+		if oldTarget.IsFile() && oldTarget.file == newTarget.file {
+			// hard links to the same file. See the comment above.
+			return nil
+		}
 		if oldTarget.IsFile() && newTarget.IsDir() {
 			return &fs.PathError{Path: oldname, Err: syscall.EISDIR}
 		}
@@ -544,12 +589,14 @@ func (fsys *Fs) rename(oldname string, newname string) error {
 		}
 	}
 
+	renamed := oldTarget.withName(pathpkg.Base(newname))
 	oldParent.removeDirent(oldTarget)
-	replaced := newParent.addDirent(oldTarget)
+	replaced := newParent.addDirent(renamed)
 	if replaced != nil {
 		replaced.notifyClose()
 	}
-	oldTarget.notifyRename(newname)
+	renamed.notifyRename(newname)
+	fsys.touch(oldParent, newParent, renamed)
 
 	return nil
 
@@ -23,45 +23,109 @@ func (f *Fs) AddFile(path string, fileData FileView) error {
 	if err != nil {
 		return wrapErr("AddFile", path, err)
 	}
-	_, err = f.addFile(path, fileData)
+	_, err = f.addFile(path, fileData, nil)
 	return wrapErr("AddFile", path, err)
 }
 
-func (f *Fs) addFile(path string, fileData FileView) (*dirent, error) {
-	dirent, err := newFileDirent(fileData, path)
+// addFile adds fileData at path.
+// allocator is passed to [Fs.newFileDirent].
+func (f *Fs) addFile(path string, fileData FileView, allocator FileViewAllocator) (*dirent, error) {
+	parent, _, err := f.prepareParent("AddFile", path)
 	if err != nil {
 		return nil, err
 	}
 
+	dirent, err := f.newFileDirent(fileData, path, allocator)
+	if err != nil {
+		return nil, err
+	}
+
+	f.replaceDirent(parent, dirent)
+	return dirent, nil
+}
+
+// prepareParent makes the parent directory of path by MkdirAll,
+// then returns it with the base name of path.
+func (f *Fs) prepareParent(op, path string) (parent *dirent, base string, err error) {
 	dir, base := pathpkg.Split(path)
 	if base == "" {
-		return nil, wrapErr("AddFile", path, fmt.Errorf("%w: root dir", fs.ErrInvalid))
+		return nil, "", wrapErr(op, path, fmt.Errorf("%w: root dir", fs.ErrInvalid))
 	}
 	dir = pathpkg.Clean(dir)
 	err = f.MkdirAll(dir, fs.ModePerm)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	parent, err := f.find(dir)
+	parent, err = f.find(dir)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if err := parent.IsWritableDir(); err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	return parent, base, nil
+}
 
-	ent, ok := parent.lookup(base)
+// replaceDirent adds dirent to parent, removing the existing one with same name if any.
+func (f *Fs) replaceDirent(parent *dirent, dirent *dirent) {
+	ent, ok := parent.lookup(dirent.name)
 	if ok {
 		ent.notifyClose()
 	}
 
 	parent.addDirent(dirent)
-	return dirent, nil
+	f.touch(parent, dirent)
+}
+
+// AddLink adds a hard link at path to the file at existing.
+// Both paths share the same FileView, permission, owner and times.
+// The FileView is closed only after all links are removed.
+//
+// Like [Fs.AddFile], nonexistent directories in the path prefix are made
+// with permission of 0o777 before umask,
+// and the existing entry at path is removed unless it is already a link to the same file.
+// It returns syscall.EPERM if existing is a directory.
+//
+// Fs does not support symbolic links.
+func (f *Fs) AddLink(path string, existing string) error {
+	err := validatePath(path)
+	if err != nil {
+		return wrapErr("AddLink", path, err)
+	}
+	src, err := f.find(existing)
+	if err != nil {
+		return wrapErr("AddLink", existing, err)
+	}
+	if src.IsDir() {
+		// link(2) does not allow hard links to directories.
+		return wrapErr("AddLink", existing, syscall.EPERM)
+	}
+
+	parent, base, err := f.prepareParent("AddLink", path)
+	if err != nil {
+		return wrapErr("AddLink", path, err)
+	}
+	if ent, ok := parent.lookup(base); ok && ent.file == src.file {
+		return nil
+	}
+
+	f.replaceDirent(parent, src.newLinkDirent(base))
+	return nil
+}
+
+// allocate allocates a new FileView using allocator,
+// passing sizeHint if allocator implements [FileViewSizeHintAllocator].
+func allocate(allocator FileViewAllocator, path string, perm fs.FileMode, sizeHint int64) FileView {
+	if a, ok := allocator.(FileViewSizeHintAllocator); ok && sizeHint > 0 {
+		return a.AllocateSize(path, perm, sizeHint)
+	}
+	return allocator.Allocate(path, perm)
 }
 
 // Reallocate allocates a new file using allocator,
 // copies the content of path into the new FileData,
 // then store it in the fsys.
+// If path is a hard link, other links keep referring to the old file.
 func (fsys *Fs) Reallocate(path string, allocator FileViewAllocator) error {
 	oldDirent, err := fsys.find(path)
 	if err != nil {
@@ -72,13 +136,18 @@ func (fsys *Fs) Reallocate(path string, allocator FileViewAllocator) error {
 		return wrapErr("Reallocate", path, syscall.EBADF)
 	}
 
-	oldFile, err := oldDirent.file.Open(os.O_RDONLY)
+	oldFile, err := oldDirent.file.Open(oldDirent.name, os.O_RDONLY)
 	if err != nil {
 		return wrapErr("Reallocate", path, err)
 	}
 	defer oldFile.Close()
 
-	newFD := allocator.Allocate(path, fs.ModePerm)
+	s, err := oldFile.Stat()
+	if err != nil {
+		return wrapErr("Reallocate", path, err)
+	}
+
+	newFD := allocate(allocator, path, fs.ModePerm, s.Size())
 	newFile, err := newFD.Open(os.O_CREATE | os.O_RDWR)
 	if err != nil {
 		return err
@@ -93,17 +162,13 @@ func (fsys *Fs) Reallocate(path string, allocator FileViewAllocator) error {
 		return err
 	}
 
-	dirent, err := fsys.addFile(path, newFD)
+	dirent, err := fsys.addFile(path, newFD, allocator)
 	if err != nil {
 		return wrapErr("AddFile", path, err)
 	}
 
 	dirent.copyMeta(oldDirent)
-
-	err = oldDirent.notifyClose()
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrClosedWithError, err)
-	}
+	fsys.touch(dirent)
 
 	return nil
 }
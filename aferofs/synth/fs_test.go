@@ -4,11 +4,15 @@ import (
 	"crypto/rand"
 	"embed"
 	_ "embed"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	pathpkg "path"
+	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"testing/fstest"
@@ -163,3 +167,228 @@ func assertStat(
 	assert.Equal(t, s.IsDir(), isDir)
 	assert.Assert(t, (s.Sys() == nil) == nilSys)
 }
+
+func TestOpenFile_flags(t *testing.T) {
+	newFsys := func(t *testing.T) *Fs {
+		t.Helper()
+		fsys := New(0o022, NewMemFileAllocator(clock.RealWallClock()))
+		assert.NilError(t, fsys.Mkdir("dir", fs.ModePerm))
+		for _, name := range []string{"file", "readonly"} {
+			f, err := fsys.Create(name)
+			assert.NilError(t, err)
+			_, err = f.Write([]byte("foobar"))
+			assert.NilError(t, err)
+			assert.NilError(t, f.Close())
+		}
+		assert.NilError(t, fsys.Chmod("readonly", 0o444))
+		return fsys
+	}
+
+	type testCase struct {
+		name string
+		flag int
+		err  error
+		size int64 // size after open, -1 if not checked.
+	}
+	for _, tc := range []testCase{
+		{"file", os.O_RDONLY, nil, 6},
+		{"file", os.O_RDWR | os.O_APPEND, nil, 6},
+		{"file", os.O_RDWR | os.O_CREATE, nil, 6},
+		{"file", os.O_RDWR | os.O_CREATE | os.O_TRUNC, nil, 0},
+		{"file", os.O_RDONLY | os.O_TRUNC, nil, 0},
+		{"file", os.O_RDWR | os.O_EXCL, nil, 6},
+		{"file", os.O_RDWR | os.O_CREATE | os.O_EXCL, syscall.EEXIST, 6},
+		{"readonly", os.O_RDONLY, nil, 6},
+		{"readonly", os.O_WRONLY, syscall.EACCES, 6},
+		{"readonly", os.O_RDONLY | os.O_TRUNC, syscall.EACCES, 6},
+		{"readonly", os.O_RDONLY | os.O_CREATE | os.O_TRUNC, syscall.EACCES, 6},
+		{"dir", os.O_RDONLY, nil, -1},
+		{"dir", os.O_WRONLY, syscall.EISDIR, -1},
+		{"dir", os.O_RDONLY | os.O_TRUNC, syscall.EISDIR, -1},
+		{"dir", os.O_RDONLY | os.O_CREATE, syscall.EISDIR, -1},
+		{".", os.O_RDONLY | os.O_CREATE, syscall.EISDIR, -1},
+		{".", os.O_RDWR, syscall.EISDIR, -1},
+		{".", os.O_RDONLY | os.O_CREATE | os.O_EXCL, syscall.EEXIST, -1},
+		{"nonexistent", os.O_RDONLY, syscall.ENOENT, -1},
+		{"nonexistent", os.O_RDONLY | os.O_TRUNC, syscall.ENOENT, -1},
+		{"nonexistent", os.O_RDONLY | os.O_CREATE, nil, 0},
+		{"nonexistent", os.O_WRONLY | os.O_CREATE | os.O_EXCL | os.O_TRUNC, nil, 0},
+	} {
+		t.Run(fmt.Sprintf("%s_%#x", tc.name, tc.flag), func(t *testing.T) {
+			fsys := newFsys(t)
+			f, err := fsys.OpenFile(tc.name, tc.flag, 0o666)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NilError(t, err)
+				assert.NilError(t, f.Close())
+			}
+			if tc.size >= 0 {
+				s, err := fsys.Stat(tc.name)
+				assert.NilError(t, err)
+				assert.Equal(t, s.Size(), tc.size)
+			}
+		})
+	}
+
+	// umask is applied to newly created files.
+	fsys := newFsys(t)
+	f, err := fsys.OpenFile("created", os.O_RDONLY|os.O_CREATE, 0o666)
+	assert.NilError(t, err)
+	_ = f.Close()
+	s, err := fsys.Stat("created")
+	assert.NilError(t, err)
+	assert.Equal(t, s.Mode().Perm(), fs.FileMode(0o644))
+}
+
+func TestMkdirAll_permission(t *testing.T) {
+	fsys := New(0, NewMemFileAllocator(clock.RealWallClock()))
+
+	_, err := fsys.Create("file")
+	assert.NilError(t, err)
+
+	var pErr *fs.PathError
+	for _, p := range []string{"file", "file/foo", "file/foo/bar"} {
+		err = fsys.MkdirAll(p, fs.ModePerm)
+		assert.ErrorIs(t, err, syscall.ENOTDIR)
+		assert.Assert(t, errors.As(err, &pErr))
+		assert.Equal(t, pErr.Path, "file")
+	}
+
+	// the last directory does not need to be searchable.
+	assert.NilError(t, fsys.MkdirAll("foo", 0o600))
+	s, err := fsys.Stat("foo")
+	assert.NilError(t, err)
+	assert.Equal(t, s.Mode().Perm(), fs.FileMode(0o600))
+	assert.ErrorIs(t, fsys.MkdirAll("foo/bar", 0o600), syscall.EACCES)
+
+	// existing directories keep their permission.
+	assert.NilError(t, fsys.MkdirAll("baz", 0o755))
+	assert.NilError(t, fsys.MkdirAll("baz/qux", fs.ModePerm))
+	s, err = fsys.Stat("baz")
+	assert.NilError(t, err)
+	assert.Equal(t, s.Mode().Perm(), fs.FileMode(0o755))
+
+	assert.NilError(t, fsys.Chmod("baz", 0o500))
+	err = fsys.MkdirAll("baz/quux/corge", fs.ModePerm)
+	assert.ErrorIs(t, err, syscall.EACCES)
+	assert.Assert(t, errors.As(err, &pErr))
+	assert.Equal(t, pErr.Path, "baz/quux")
+	// already existing path still succeeds.
+	assert.NilError(t, fsys.MkdirAll("baz/qux", fs.ModePerm))
+}
+
+func TestPermissionErrors(t *testing.T) {
+	setup := func(t *testing.T, fsys afero.Fs) {
+		t.Helper()
+		for _, dir := range []string{"noexec", "nowrite", "nowrite/dir"} {
+			assert.NilError(t, fsys.Mkdir(dir, 0o755))
+		}
+		for _, name := range []string{"noexec/file", "nowrite/file", "ro"} {
+			f, err := fsys.OpenFile(name, os.O_CREATE|os.O_RDWR, 0o644)
+			assert.NilError(t, err)
+			assert.NilError(t, f.Close())
+		}
+		assert.NilError(t, fsys.Chmod("ro", 0o444))
+		assert.NilError(t, fsys.Chmod("noexec", 0o644))
+		assert.NilError(t, fsys.Chmod("nowrite", 0o555))
+		t.Cleanup(func() {
+			_ = fsys.Chmod("noexec", 0o755)
+			_ = fsys.Chmod("nowrite", 0o755)
+		})
+	}
+
+	open := func(name string, flag int) func(fsys afero.Fs) error {
+		return func(fsys afero.Fs) error {
+			f, err := fsys.OpenFile(name, flag, 0o644)
+			if err == nil {
+				_ = f.Close()
+			}
+			return err
+		}
+	}
+
+	cases := []struct {
+		name string
+		op   func(fsys afero.Fs) error
+		err  error
+	}{
+		{"stat under noexec", func(fsys afero.Fs) error { _, err := fsys.Stat("noexec/file"); return err }, syscall.EACCES},
+		{"open under noexec", open("noexec/file", os.O_RDONLY), syscall.EACCES},
+		{"create under noexec", open("noexec/new", os.O_CREATE|os.O_RDWR), syscall.EACCES},
+		{"chmod under noexec", func(fsys afero.Fs) error { return fsys.Chmod("noexec/file", 0o600) }, syscall.EACCES},
+		{"mkdir under noexec", func(fsys afero.Fs) error { return fsys.Mkdir("noexec/new", 0o755) }, syscall.EACCES},
+		{"remove under noexec", func(fsys afero.Fs) error { return fsys.Remove("noexec/file") }, syscall.EACCES},
+		{"create under nowrite", open("nowrite/new", os.O_CREATE|os.O_RDWR), syscall.EACCES},
+		{"mkdir under nowrite", func(fsys afero.Fs) error { return fsys.Mkdir("nowrite/new", 0o755) }, syscall.EACCES},
+		{"mkdir existing under nowrite", func(fsys afero.Fs) error { return fsys.Mkdir("nowrite/dir", 0o755) }, syscall.EEXIST},
+		{"mkdirall under nowrite", func(fsys afero.Fs) error { return fsys.MkdirAll("nowrite/a/b", 0o755) }, syscall.EACCES},
+		{"remove under nowrite", func(fsys afero.Fs) error { return fsys.Remove("nowrite/file") }, syscall.EACCES},
+		{"remove nonexistent under nowrite", func(fsys afero.Fs) error { return fsys.Remove("nowrite/nonexistent") }, syscall.ENOENT},
+		{"rename from nowrite", func(fsys afero.Fs) error { return fsys.Rename("nowrite/file", "moved") }, syscall.EACCES},
+		{"open readonly file for write", open("ro", os.O_WRONLY), syscall.EACCES},
+		{"mkdir under file", func(fsys afero.Fs) error { return fsys.Mkdir("ro/dir", 0o755) }, syscall.ENOTDIR},
+	}
+
+	run := func(t *testing.T, newFsys func(t *testing.T) afero.Fs) {
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				fsys := newFsys(t)
+				setup(t, fsys)
+				assert.ErrorIs(t, tc.op(fsys), tc.err)
+			})
+		}
+	}
+
+	t.Run("synth", func(t *testing.T) {
+		run(t, func(t *testing.T) afero.Fs { return New(0, NewMemFileAllocator(clock.RealWallClock())) })
+	})
+	t.Run("os", func(t *testing.T) {
+		if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+			t.Skip("permission bits are not enforced")
+		}
+		run(t, func(t *testing.T) afero.Fs { return afero.NewBasePathFs(afero.NewOsFs(), t.TempDir()) })
+	})
+}
+
+func TestRename_concurrent(t *testing.T) {
+	fsys := New(0, NewMemFileAllocator(clock.RealWallClock()))
+	assert.NilError(t, afero.WriteFile(fsys, "foo", []byte("foo"), fs.ModePerm))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 1000 {
+			from, to := "foo", "bar"
+			if i%2 == 1 {
+				from, to = to, from
+			}
+			if !assert.Check(t, fsys.Rename(from, to)) {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 1000 {
+			for _, name := range []string{"foo", "bar"} {
+				f, err := fsys.Open(name)
+				if err != nil {
+					continue
+				}
+				_, _ = f.Stat()
+				_ = f.Close()
+			}
+			_, err := afero.ReadDir(fsys, ".")
+			if !assert.Check(t, err) {
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	bin, err := afero.ReadFile(fsys, "foo")
+	assert.NilError(t, err)
+	assert.Equal(t, "foo", string(bin))
+}
@@ -3,44 +3,138 @@ package synth
 import (
 	"io/fs"
 	"path"
+	"runtime"
+	"sync"
 
 	"github.com/ngicks/go-fsys-helper/aferofs/clock"
 	"github.com/spf13/afero"
 )
 
-var _ FileViewAllocator = (*MemFileAllocator)(nil)
+var _ FileViewSizeHintAllocator = (*MemFileAllocator)(nil)
 
+// MemFileAllocator allocates FileView backed by in-memory byte slices.
+//
+// MemFileAllocator keeps track of files it has allocated
+// until they are closed by *Fs,
+// i.e. they are removed from *Fs and all handles to them are closed,
+// or until they are garbage collected.
+// [MemFileAllocator.Stats] reports memory held by those files
+// and [MemFileAllocator.Compact] releases unused capacity.
 type MemFileAllocator struct {
 	clock clock.WallClock
+
+	mu    sync.Mutex
+	files map[*memFile]struct{}
 }
 
 func NewMemFileAllocator(clock clock.WallClock) *MemFileAllocator {
 	return &MemFileAllocator{
 		clock: clock,
+		files: make(map[*memFile]struct{}),
 	}
 }
 
 func (m *MemFileAllocator) Allocate(path string, perm fs.FileMode) FileView {
-	return &memFileData{
-		path: path,
-		file: newMemFile(perm.Perm(), m.clock),
+	return m.AllocateSize(path, perm, 0)
+}
+
+// maxSizeHint limits capacity reserved by [MemFileAllocator.AllocateSize]
+// so that an untrusted hint, e.g. a size in an archive header, can not exhaust memory up front.
+const maxSizeHint = 16 * 1024 * 1024
+
+// AllocateSize is like Allocate but reserves sizeHint bytes of capacity for the file.
+// Reservation is capped to 16MiB; larger files grow as they are written.
+func (m *MemFileAllocator) AllocateSize(path string, perm fs.FileMode, sizeHint int64) FileView {
+	f := newMemFile(perm.Perm(), m.clock)
+	if sizeHint > smallBlockSize {
+		f.content = make([]byte, 0, min(sizeHint, maxSizeHint))
+	}
+
+	m.mu.Lock()
+	m.files[f] = struct{}{}
+	m.mu.Unlock()
+
+	data := &memFileData{
+		path:  path,
+		file:  f,
+		alloc: m,
+	}
+	// Views dropped without being closed, e.g. files of a discarded *Fs,
+	// must not be tracked forever.
+	runtime.SetFinalizer(data, (*memFileData).Close)
+	return data
+}
+
+// forget stops tracking f and returns its buffer to the pool.
+func (m *MemFileAllocator) forget(f *memFile) {
+	m.mu.Lock()
+	_, tracked := m.files[f]
+	delete(m.files, f)
+	m.mu.Unlock()
+	if tracked {
+		f.release()
+	}
+}
+
+func (m *MemFileAllocator) snapshot() []*memFile {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make([]*memFile, 0, len(m.files))
+	for f := range m.files {
+		files = append(files, f)
+	}
+	return files
+}
+
+// MemStats describes memory usage of files allocated by [MemFileAllocator].
+type MemStats struct {
+	// Files is the number of files that still hold memory.
+	// Files removed from *Fs are counted until all handles to them are closed.
+	Files int
+	// Size is the sum of file sizes in bytes.
+	Size int64
+	// Capacity is the sum of buffer capacities in bytes.
+	// Capacity - Size is the amount that [MemFileAllocator.Compact] can release.
+	Capacity int64
+}
+
+// Stats returns memory usage of files allocated by m.
+func (m *MemFileAllocator) Stats() MemStats {
+	var stats MemStats
+	for _, f := range m.snapshot() {
+		size, capacity := f.usage()
+		stats.Files++
+		stats.Size += int64(size)
+		stats.Capacity += int64(capacity)
+	}
+	return stats
+}
+
+// Compact shrinks buffers of all files allocated by m to their size,
+// releasing unused capacity left by writes and truncation.
+// Files can still be read and written during and after Compact.
+func (m *MemFileAllocator) Compact() {
+	for _, f := range m.snapshot() {
+		f.compact()
 	}
 }
 
 var _ FileView = (*memFileData)(nil)
 
 type memFileData struct {
-	path string
-	file *memFile
+	path  string
+	file  *memFile
+	alloc *MemFileAllocator
 }
 
 func (m *memFileData) Close() error {
-	// currently nothing
+	runtime.SetFinalizer(m, nil)
+	m.alloc.forget(m.file)
 	return nil
 }
 
 func (m *memFileData) Open(flag int) (afero.File, error) {
-	return newMemFileHandle(m.file, m.path, flag), nil
+	return newMemFileHandle(m, m.path, flag), nil
 }
 
 func (m *memFileData) Stat() (fs.FileInfo, error) {
@@ -19,21 +19,42 @@ import (
 var _ afero.File = (*memFileHandle)(nil)
 
 type memFileHandle struct {
-	mu   sync.Mutex
+	// view keeps the memFileData reachable while the handle is in use,
+	// so that its finalizer does not release file.
+	view *memFileData
 	file *memFile
 	path string
-	off  int64
+	pos  *filePos
 	flag int
 }
 
-func newMemFileHandle(file *memFile, path string, flag int) *memFileHandle {
+// filePos is the file offset, shared among handles duplicated by Dup.
+type filePos struct {
+	mu  sync.Mutex
+	off int64
+}
+
+func newMemFileHandle(view *memFileData, path string, flag int) *memFileHandle {
 	return &memFileHandle{
-		file: file,
+		view: view,
+		file: view.file,
 		path: path,
+		pos:  &filePos{},
 		flag: flag,
 	}
 }
 
+// Dup returns a new handle sharing the file offset with f.
+func (f *memFileHandle) Dup() (afero.File, error) {
+	return &memFileHandle{
+		view: f.view,
+		file: f.file,
+		path: f.path,
+		pos:  f.pos,
+		flag: f.flag,
+	}, nil
+}
+
 func (f *memFileHandle) Close() error {
 	// close is handled by wrapper
 	return nil
@@ -47,11 +68,11 @@ func (f *memFileHandle) Read(p []byte) (n int, err error) {
 	if !flagReadable(f.flag) {
 		return 0, errdef.ReadBadf(f.path)
 	}
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	n, err = f.file.ReadAt(p, f.off)
+	f.pos.mu.Lock()
+	defer f.pos.mu.Unlock()
+	n, err = f.file.ReadAt(p, f.pos.off)
 	err = wrapErr("read", f.path, err)
-	f.off += int64(n)
+	f.pos.off += int64(n)
 	return
 }
 
@@ -71,15 +92,15 @@ func (f *memFileHandle) Readdirnames(n int) ([]string, error) {
 }
 
 func (f *memFileHandle) Seek(offset int64, whence int) (int64, error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	f.pos.mu.Lock()
+	defer f.pos.mu.Unlock()
 
 	switch whence {
 	default:
 		return 0, errdef.SeekInval(f.path, fmt.Sprintf("unknown whence: %d", whence))
 	case io.SeekStart:
 	case io.SeekCurrent:
-		offset += f.off
+		offset += f.pos.off
 	case io.SeekEnd:
 		offset += int64(f.file.Len())
 	}
@@ -88,9 +109,9 @@ func (f *memFileHandle) Seek(offset int64, whence int) (int64, error) {
 		return 0, errdef.SeekInval(f.path, "negative offset")
 	}
 
-	f.off = offset
+	f.pos.off = offset
 
-	return f.off, nil
+	return f.pos.off, nil
 }
 
 func (f *memFileHandle) Stat() (fs.FileInfo, error) {
@@ -116,15 +137,15 @@ func (f *memFileHandle) Write(p []byte) (n int, err error) {
 		return 0, errdef.WriteBadf(f.path)
 	}
 
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	f.pos.mu.Lock()
+	defer f.pos.mu.Unlock()
 
 	if f.flag&os.O_APPEND != 0 {
-		f.off = int64(f.file.Len())
+		f.pos.off = int64(f.file.Len())
 	}
-	n, err = f.file.WriteAt(p, f.off)
+	n, err = f.file.WriteAt(p, f.pos.off)
 	err = wrapErr("write", f.path, err)
-	f.off += int64(n)
+	f.pos.off += int64(n)
 	return
 }
 
@@ -154,6 +175,29 @@ var (
 	_ io.WriterAt = (*memFile)(nil)
 )
 
+// smallBlockSize is the capacity of buffers pooled for small files.
+const smallBlockSize = 4 * 1024
+
+var smallBlockPool = &sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, smallBlockSize)
+		return &b
+	},
+}
+
+func getSmallBlock() []byte {
+	return (*smallBlockPool.Get().(*[]byte))[:0]
+}
+
+func putSmallBlock(b []byte) {
+	if cap(b) != smallBlockSize {
+		// only blocks taken from the pool are returned.
+		return
+	}
+	b = b[:0]
+	smallBlockPool.Put(&b)
+}
+
 type memFile struct {
 	clock clock.WallClock
 
@@ -183,6 +227,41 @@ func (f *memFile) stat(name string) stat {
 	return stat{f.mode, f.modTime, name, int64(len(f.content))}
 }
 
+func (f *memFile) usage() (size, capacity int) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.content), cap(f.content)
+}
+
+// release returns the buffer to the pool if possible.
+// f must not be used after release.
+func (f *memFile) release() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	putSmallBlock(f.content)
+	f.content = nil
+}
+
+func (f *memFile) compact() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cap(f.content) > len(f.content) {
+		f.reallocExact()
+	}
+}
+
+// reallocExact reallocates f.content so that its capacity is same as its length.
+func (f *memFile) reallocExact() {
+	old := f.content
+	if len(old) == 0 {
+		f.content = nil
+	} else {
+		f.content = make([]byte, len(old))
+		copy(f.content, old)
+	}
+	putSmallBlock(old)
+}
+
 func (f *memFile) Truncate(size int64) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -192,8 +271,14 @@ func (f *memFile) Truncate(size int64) error {
 	diff := size - int64(len(f.content))
 	if diff > 0 {
 		f.grow(int(diff))
+		return nil
+	}
+	f.content = f.content[:size]
+	// Release the buffer if more than half of it is left unused.
+	// Small blocks are kept since they are cheap and likely to be written again.
+	if cap(f.content) > smallBlockSize && cap(f.content)-len(f.content) > len(f.content) {
+		f.reallocExact()
 	}
-	f.content = f.content[:size:size] // release unused portion
 	return nil
 }
 
@@ -247,8 +332,13 @@ func (f *memFile) WriteAt(p []byte, off int64) (n int, err error) {
 }
 
 func (f *memFile) grow(growth int) {
+	if cap(f.content) == 0 && growth <= smallBlockSize {
+		f.content = getSmallBlock()
+	}
 	if cap(f.content)-len(f.content) >= growth {
+		// Truncate may have left stale bytes beyond len.
 		f.content = f.content[:len(f.content)+growth]
+		clear(f.content[len(f.content)-growth:])
 	} else {
 		// TODO: prevent this over allocation?
 		f.content = append(f.content, make([]byte, growth)...)
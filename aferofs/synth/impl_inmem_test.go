@@ -0,0 +1,79 @@
+package synth
+
+import (
+	"io/fs"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ngicks/go-fsys-helper/aferofs/clock"
+	"gotest.tools/v3/assert"
+)
+
+func TestMemFileAllocator_Stats(t *testing.T) {
+	alloc := NewMemFileAllocator(clock.RealWallClock())
+	fsys := New(0, alloc)
+
+	f, err := fsys.OpenFile("foo", os.O_CREATE|os.O_RDWR, fs.ModePerm)
+	assert.NilError(t, err)
+	_, err = f.Write(make([]byte, 64*1024))
+	assert.NilError(t, err)
+
+	stats := alloc.Stats()
+	assert.Equal(t, stats.Files, 1)
+	assert.Equal(t, stats.Size, int64(64*1024))
+
+	assert.NilError(t, f.Truncate(10))
+	alloc.Compact()
+	stats = alloc.Stats()
+	assert.Equal(t, stats.Size, int64(10))
+	assert.Equal(t, stats.Capacity, int64(10))
+
+	// grown region must not expose stale content.
+	assert.NilError(t, f.Truncate(20))
+	var buf [20]byte
+	_, err = f.ReadAt(buf[:], 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, buf, [20]byte{})
+
+	// unlinked but still open.
+	assert.NilError(t, fsys.Remove("foo"))
+	assert.Equal(t, alloc.Stats().Files, 1)
+	_, err = f.ReadAt(buf[:], 0)
+	assert.NilError(t, err)
+
+	assert.NilError(t, f.Close())
+	assert.Equal(t, alloc.Stats(), MemStats{})
+}
+
+func TestMemFileAllocator_AddFile(t *testing.T) {
+	alloc := NewMemFileAllocator(clock.RealWallClock())
+	fsys := NewNoAlloc(0)
+
+	assert.NilError(t, fsys.AddFile("foo", alloc.Allocate("foo", fs.ModePerm)))
+	assert.Equal(t, alloc.Stats().Files, 1)
+
+	assert.NilError(t, fsys.Remove("foo"))
+	assert.Equal(t, alloc.Stats(), MemStats{})
+}
+
+func TestMemFileAllocator_dropFs(t *testing.T) {
+	alloc := NewMemFileAllocator(clock.RealWallClock())
+	func() {
+		fsys := New(0, alloc)
+		f, err := fsys.Create("foo")
+		assert.NilError(t, err)
+		assert.NilError(t, f.Close())
+	}()
+	assert.Equal(t, alloc.Stats().Files, 1)
+
+	for range 100 {
+		runtime.GC()
+		if alloc.Stats().Files == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("files of dropped Fs are still tracked: %#v", alloc.Stats())
+}
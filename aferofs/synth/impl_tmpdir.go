@@ -48,8 +48,9 @@ func (b *tmpDirFileView) Close() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	if b.path != "" {
+		path := b.path
 		b.path = ""
-		return b.fsys.Remove(b.path)
+		return b.fsys.Remove(path)
 	}
 	return nil
 }
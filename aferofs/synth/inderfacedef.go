@@ -11,6 +11,36 @@ type FileViewAllocator interface {
 	Allocate(path string, perm fs.FileMode) FileView
 }
 
+// FileViewSizeHintAllocator is an optional interface for [FileViewAllocator].
+//
+// *Fs calls AllocateSize instead of Allocate when the size of the content
+// to be written is known beforehand, e.g. by [Fs.Reallocate] and [Fs.AddArchive].
+// Implementations may use sizeHint to reserve storage.
+type FileViewSizeHintAllocator interface {
+	FileViewAllocator
+	AllocateSize(path string, perm fs.FileMode, sizeHint int64) FileView
+}
+
+// FileViewSizeTracker is an optional interface for [FileViewAllocator].
+//
+// Grow and Shrink are called when size of a FileView allocated by the allocator
+// is changed by writes or truncation made through *Fs.
+// Changes made through files opened by calling Open method of FileView directly
+// are not tracked.
+type FileViewSizeTracker interface {
+	Grow(view FileView, oldSize, newSize int64)
+	Shrink(view FileView, oldSize, newSize int64)
+}
+
+// FileViewReleaser is an optional interface for [FileViewAllocator].
+//
+// Release is called with a FileView allocated by the allocator
+// right after the FileView is closed by *Fs.
+// See Close method of [FileView] for when it happens.
+type FileViewReleaser interface {
+	Release(view FileView)
+}
+
 // FileView is a pointer to a file-like data stored in a backing storage.
 //
 // FileView is currently only assumed to be a regular file.
@@ -32,10 +62,18 @@ type FileView interface {
 	// Readonly implementations may return a bare syscall.EROFS, or similar errors.
 	Truncate(size int64) error
 	// Close notifies the backing storage
-	// that this FileView is no longer referred by name.
+	// that this FileView is no longer used by *Fs.
+	//
+	// *Fs calls Close exactly once, when the FileView is no longer referred by name,
+	// e.g. removed or replaced by other file, and all files opened through *Fs are closed.
+	// To add the same FileView to multiple paths or multiple *Fs instances,
+	// wrap it with [Share] so that Close is called only after the last one is released.
+	// Otherwise Close is called on each release.
+	// Until then files opened through *Fs keep working after removal,
+	// just like unlinking opened files on unix-like systems.
 	//
-	// The file opened by calling Open method may still
-	// exist and be used.
+	// Files opened by calling Open method directly
+	// may still exist and be used.
 	//
 	// The returned error might be ignored.
 	Close() error
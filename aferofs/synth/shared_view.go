@@ -0,0 +1,60 @@
+package synth
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// Share wraps view so that it can be added to multiple paths or multiple *Fs instances.
+//
+// *Fs counts references to the returned FileView and
+// closes view only after the last one is released.
+// The count is held by the returned FileView itself,
+// thus nothing is kept once all *Fs referring it are dropped.
+//
+// Once the last reference is released and view is closed,
+// adding the returned FileView again fails with [fs.ErrClosed].
+//
+// Without Share, the same FileView added more than once is closed on each release.
+func Share(view FileView) FileView {
+	if s, ok := view.(*sharedView); ok {
+		return s
+	}
+	return &sharedView{FileView: view}
+}
+
+type sharedView struct {
+	FileView
+
+	mu     sync.Mutex
+	refs   int
+	closed bool
+}
+
+// acquire increments reference count of s.
+// It returns fs.ErrClosed if s is already closed.
+func (s *sharedView) acquire() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fs.ErrClosed
+	}
+	s.refs++
+	return nil
+}
+
+// release decrements reference count of s.
+// It closes s and returns true if no reference is left.
+func (s *sharedView) release() (closed bool, err error) {
+	s.mu.Lock()
+	s.refs--
+	n := s.refs
+	if n <= 0 {
+		s.closed = true
+	}
+	s.mu.Unlock()
+	if n > 0 {
+		return false, nil
+	}
+	return true, s.FileView.Close()
+}
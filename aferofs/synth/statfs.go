@@ -0,0 +1,81 @@
+package synth
+
+import "math"
+
+// StatfsFs is an optional interface for filesystems that can report their usage.
+type StatfsFs interface {
+	Statfs() (Statfs, error)
+}
+
+var _ StatfsFs = (*Fs)(nil)
+
+// Statfs describes usage of a filesystem, loosely modeled after statfs(2).
+type Statfs struct {
+	// BlockSize is the size of a block in bytes.
+	BlockSize int64
+	// TotalBytes is the capacity of the filesystem in bytes.
+	// It is math.MaxInt64 if the filesystem has no limit.
+	TotalBytes int64
+	// UsedBytes is the amount of bytes in use.
+	UsedBytes int64
+	// TotalFiles is the maximum number of files, including directories.
+	// It is math.MaxInt64 if the filesystem has no limit.
+	TotalFiles int64
+	// Files is the number of files, including directories.
+	Files int64
+}
+
+// synthBlockSize is the size of a block reported by [Fs.Statfs].
+// It is also the size of directories reported by Stat.
+const synthBlockSize = 4096
+
+// Statfs returns synthetic usage of fsys.
+//
+// Fs has no limit on capacity, so TotalBytes and TotalFiles are math.MaxInt64.
+// UsedBytes sums up sizes of all files rounded up to the block size
+// and a block for each directory, including the root.
+// Sizes of files are taken by Stat of their FileView regardless of the backing storage.
+func (fsys *Fs) Statfs() (Statfs, error) {
+	stat := Statfs{
+		BlockSize:  synthBlockSize,
+		TotalBytes: math.MaxInt64,
+		TotalFiles: math.MaxInt64,
+	}
+	err := statfsDirent(fsys.root, &stat, make(map[*virtualFileData]struct{}))
+	if err != nil {
+		return Statfs{}, wrapErr("statfs", ".", err)
+	}
+	return stat, nil
+}
+
+// statfsDirent adds usage of ent and its descendants to stat.
+// Hard links are counted once; seen holds files already counted.
+func statfsDirent(ent *dirent, stat *Statfs, seen map[*virtualFileData]struct{}) error {
+	if ent.IsFile() {
+		if _, ok := seen[ent.file]; ok {
+			return nil
+		}
+		seen[ent.file] = struct{}{}
+		stat.Files++
+		s, err := ent.stat()
+		if err != nil {
+			return err
+		}
+		blocks := (s.Size() + synthBlockSize - 1) / synthBlockSize
+		stat.UsedBytes += blocks * synthBlockSize
+		return nil
+	}
+	stat.Files++
+	stat.UsedBytes += synthBlockSize
+	for _, name := range ent.dir.ListName() {
+		child, ok := ent.lookup(name)
+		if !ok {
+			// removed concurrently.
+			continue
+		}
+		if err := statfsDirent(child, stat, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package synth
+
+import (
+	"io/fs"
+	"math"
+	"testing"
+
+	"github.com/ngicks/go-fsys-helper/aferofs/clock"
+	"gotest.tools/v3/assert"
+)
+
+func TestStatfs(t *testing.T) {
+	fsys := New(0, NewMemFileAllocator(clock.RealWallClock()))
+
+	assert.NilError(t, fsys.MkdirAll("foo/bar", fs.ModePerm))
+	for name, size := range map[string]int{"foo/a": 1, "foo/bar/b": 4096, "c": 4097} {
+		f, err := fsys.Create(name)
+		assert.NilError(t, err)
+		_, err = f.Write(make([]byte, size))
+		assert.NilError(t, err)
+		assert.NilError(t, f.Close())
+	}
+
+	stat, err := fsys.Statfs()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, stat, Statfs{
+		BlockSize:  4096,
+		TotalBytes: math.MaxInt64,
+		// 3 dirs + 1 + 1 + 2 blocks
+		UsedBytes:  7 * 4096,
+		TotalFiles: math.MaxInt64,
+		Files:      6,
+	})
+
+	// hard links are counted once.
+	assert.NilError(t, fsys.AddLink("foo/bar/c", "c"))
+	assert.NilError(t, fsys.AddLink("d", "c"))
+	linked, err := fsys.Statfs()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, linked, stat)
+}
@@ -0,0 +1,27 @@
+package synth
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// validatePathPlatform applies platform specific restrictions to path.
+//
+// Paths are slash-separated on windows as well.
+// Since callers on windows are likely to pass paths built by path/filepath,
+// paths that would be interpreted differently by the OS are rejected
+// rather than silently being treated as names containing a backslash or a colon:
+// paths with a volume name, e.g. `C:` or `C:/foo`, and paths containing backslashes,
+// which includes UNC paths like `\\server\share`.
+// Convert them with filepath.ToSlash and strip the volume name before passing them to *Fs.
+func validatePathPlatform(path string) error {
+	if strings.Contains(path, `\`) {
+		return fmt.Errorf("%w: path must be slash-separated: %q", fs.ErrInvalid, path)
+	}
+	if vol := filepath.VolumeName(path); vol != "" {
+		return fmt.Errorf("%w: path must not have volume name %q", fs.ErrInvalid, vol)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package synth
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/ngicks/go-fsys-helper/aferofs/clock"
+	"gotest.tools/v3/assert"
+)
+
+func TestWindowsPath(t *testing.T) {
+	fsys := New(0, NewMemFileAllocator(clock.RealWallClock()))
+	assert.NilError(t, fsys.MkdirAll("foo/bar", fs.ModePerm))
+
+	for _, p := range []string{
+		`C:`,
+		`C:/foo`,
+		`c:foo`,
+		`foo\bar`,
+		`\foo`,
+		`\\server\share\foo`,
+		`\\?\C:\foo`,
+	} {
+		_, err := fsys.Stat(p)
+		assert.ErrorIs(t, err, fs.ErrInvalid, "path = %q", p)
+		_, err = fsys.OpenFile(p, os.O_CREATE|os.O_RDWR, fs.ModePerm)
+		assert.ErrorIs(t, err, fs.ErrInvalid, "path = %q", p)
+		err = fsys.MkdirAll(p, fs.ModePerm)
+		assert.ErrorIs(t, err, fs.ErrInvalid, "path = %q", p)
+	}
+
+	// slash-separated relative paths are accepted.
+	_, err := fsys.Stat("foo/bar")
+	assert.NilError(t, err)
+}